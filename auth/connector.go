@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// CookieName is the cookie a connector-backed login's session is set under,
+// matching the session cookie used by the rest of Sync Gateway's login flows.
+const CookieName = "SyncGatewaySession"
+
+// Identity is the verified result of a successful login or refresh against
+// an upstream AuthConnector.  On a connector login each of the identity's
+// groups is granted to the Sync Gateway user as an explicit channel with the
+// same name; no other mapping from groups to channels is applied.
+type Identity struct {
+	Subject string   // Stable upstream identifier (sub claim, DN, NameID, etc.)
+	Email   string
+	Groups  []string // Upstream group/role membership, granted as same-named channels at login
+}
+
+// AuthConnector is implemented by each upstream identity provider that can
+// be registered with a database: OIDC, plain OAuth2, LDAP, SAML, and so on.
+// A database may register several connectors side-by-side under distinct
+// names, the way identity brokers like Dex fan out to multiple upstreams
+// behind one issuer.
+type AuthConnector interface {
+	// Login exchanges a provider-specific credential (an OAuth2 code, an
+	// OIDC id_token, an LDAP bind, a SAML assertion) for a verified Identity.
+	Login(ctx context.Context, credential string) (*Identity, error)
+
+	// Refresh re-validates a previously issued Identity and returns an
+	// updated one, e.g. after the upstream's group membership changed.
+	Refresh(ctx context.Context, identity *Identity) (*Identity, error)
+
+	// Groups returns the upstream groups for identity, for sync functions
+	// that want to re-resolve membership without a full Refresh.
+	Groups(ctx context.Context, identity *Identity) ([]string, error)
+}
+
+// ConnectorRegistry holds named AuthConnectors, keyed by the name under
+// which they were added.  It has no notion of databases: whoever owns a
+// registry decides which databases its connectors serve.
+type ConnectorRegistry struct {
+	connectors map[string]AuthConnector
+}
+
+// NewConnectorRegistry returns an empty ConnectorRegistry.
+func NewConnectorRegistry() *ConnectorRegistry {
+	return &ConnectorRegistry{connectors: map[string]AuthConnector{}}
+}
+
+// Add registers c under name, replacing any existing connector with that name.
+func (r *ConnectorRegistry) Add(name string, c AuthConnector) {
+	r.connectors[name] = c
+}
+
+// Get returns the connector registered under name, or an error if none exists.
+func (r *ConnectorRegistry) Get(name string) (AuthConnector, error) {
+	c, ok := r.connectors[name]
+	if !ok {
+		return nil, fmt.Errorf("no AuthConnector registered with name %q", name)
+	}
+	return c, nil
+}
+
+// Names returns the names of all registered connectors.
+func (r *ConnectorRegistry) Names() []string {
+	names := make([]string, 0, len(r.connectors))
+	for name := range r.connectors {
+		names = append(names, name)
+	}
+	return names
+}
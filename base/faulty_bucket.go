@@ -0,0 +1,101 @@
+package base
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/couchbase/sg-bucket"
+)
+
+// FaultSpec configures which operations FaultyBucket should inject faults
+// into.  Each field is independent; zero values mean "inject nothing" for
+// that operation.  This gives the existing CreateDoublingSleeperFunc/
+// RetryLoop code paths deterministic coverage instead of relying on real
+// cluster flakiness to exercise them.
+type FaultSpec struct {
+	// ViewErrorCount is the number of consecutive View/ViewCustom calls that
+	// fail with a transient error before calls start succeeding.
+	ViewErrorCount int
+
+	// CasMismatchCount is the number of consecutive Set calls that fail as
+	// though they lost a CAS race, before calls start succeeding.
+	CasMismatchCount int
+
+	// XattrNotFoundEvery, if non-zero, makes every Nth GetWithXattr call
+	// return sgbucket.ErrKeyNotFound, simulating an xattr op racing a
+	// concurrent write.
+	XattrNotFoundEvery int
+}
+
+// FaultyBucket wraps a Bucket and injects the faults described by its
+// FaultSpec, so retry/error-handling code paths can be tested without a
+// real flaky cluster.
+type FaultyBucket struct {
+	Bucket
+	spec FaultSpec
+
+	mu         sync.Mutex
+	viewCalls  int
+	casCalls   int
+	xattrCalls int
+}
+
+// NewFaultyBucket wraps bucket so that it injects the faults in spec.
+func NewFaultyBucket(bucket Bucket, spec FaultSpec) *FaultyBucket {
+	return &FaultyBucket{Bucket: bucket, spec: spec}
+}
+
+// View injects ViewErrorCount transient failures before delegating.
+func (b *FaultyBucket) View(ddoc, name string, params map[string]interface{}) (sgbucket.ViewResult, error) {
+	if err := b.nextViewFault(); err != nil {
+		return sgbucket.ViewResult{}, err
+	}
+	return b.Bucket.View(ddoc, name, params)
+}
+
+// ViewCustom injects ViewErrorCount transient failures before delegating.
+func (b *FaultyBucket) ViewCustom(ddoc, name string, params map[string]interface{}, vres interface{}) error {
+	if err := b.nextViewFault(); err != nil {
+		return err
+	}
+	return b.Bucket.ViewCustom(ddoc, name, params, vres)
+}
+
+func (b *FaultyBucket) nextViewFault() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.viewCalls < b.spec.ViewErrorCount {
+		b.viewCalls++
+		return fmt.Errorf("FaultyBucket: injected view error (call %d of %d)", b.viewCalls, b.spec.ViewErrorCount)
+	}
+	return nil
+}
+
+// Set injects CasMismatchCount consecutive CAS-mismatch failures before
+// delegating.
+func (b *FaultyBucket) Set(k string, exp uint32, v interface{}) error {
+	b.mu.Lock()
+	if b.casCalls < b.spec.CasMismatchCount {
+		b.casCalls++
+		calls := b.casCalls
+		b.mu.Unlock()
+		return fmt.Errorf("FaultyBucket: injected CAS mismatch on Set(%q) (call %d of %d)", k, calls, b.spec.CasMismatchCount)
+	}
+	b.mu.Unlock()
+	return b.Bucket.Set(k, exp, v)
+}
+
+// GetWithXattr injects an intermittent not-found error every
+// XattrNotFoundEvery calls before delegating.
+func (b *FaultyBucket) GetWithXattr(k string, xattr string, rv interface{}, xv interface{}) (uint64, uint64, error) {
+	if every := b.spec.XattrNotFoundEvery; every > 0 {
+		b.mu.Lock()
+		b.xattrCalls++
+		hit := b.xattrCalls%every == 0
+		b.mu.Unlock()
+		if hit {
+			return 0, 0, sgbucket.ErrKeyNotFound
+		}
+	}
+	return b.Bucket.GetWithXattr(k, xattr, rv, xv)
+}
@@ -0,0 +1,160 @@
+// Package health provides a small registry of named, periodically-run
+// dependency checks, served as a JSON status map over HTTP, so callers of
+// /_health get a single cached answer instead of each probing every
+// dependency itself.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker is a single dependency probe: it returns nil when the dependency
+// is healthy, or an error describing why it isn't.
+type Checker func() error
+
+type check struct {
+	check     Checker
+	period    time.Duration
+	threshold int // consecutive failures required to flip unhealthy
+
+	mu          sync.Mutex
+	lastErr     error
+	failStreak  int
+	unhealthy   bool
+	stop        chan struct{}
+}
+
+// Registry runs a set of named Checkers on their own periodic schedule and
+// caches the latest result, so that serving /_health never blocks on a slow
+// or hung dependency.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]*check
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]*check{}}
+}
+
+// Register adds a named Checker that runs every period in the background.
+// threshold is the number of consecutive failures required before the check
+// is reported unhealthy; pass 1 to report the first failure immediately.
+// Register starts the check's background goroutine immediately.
+func (r *Registry) Register(name string, period time.Duration, threshold int, checkFn Checker) {
+	if threshold < 1 {
+		threshold = 1
+	}
+	c := &check{
+		check:     checkFn,
+		period:    period,
+		threshold: threshold,
+		stop:      make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	if old, exists := r.checks[name]; exists {
+		close(old.stop)
+	}
+	r.checks[name] = c
+	r.mu.Unlock()
+
+	go c.run()
+}
+
+func (c *check) run() {
+	c.runOnce()
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.runOnce()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *check) runOnce() {
+	err := c.check()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+	if err != nil {
+		c.failStreak++
+	} else {
+		c.failStreak = 0
+	}
+	c.unhealthy = c.failStreak >= c.threshold
+}
+
+// Status is the cached, most recent result for a single named check.
+type Status struct {
+	Healthy bool
+	Err     error
+}
+
+// Statuses returns the cached status of every registered check.
+func (r *Registry) Statuses() map[string]Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]Status, len(r.checks))
+	for name, c := range r.checks {
+		c.mu.Lock()
+		result[name] = Status{Healthy: !c.unhealthy, Err: c.lastErr}
+		c.mu.Unlock()
+	}
+	return result
+}
+
+// IsHealthy reports whether every registered check is currently healthy.
+func (r *Registry) IsHealthy() bool {
+	for _, status := range r.Statuses() {
+		if !status.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler serves a JSON map of check name to "OK" or the check's error
+// message, responding 200 if every check is healthy and 503 otherwise.
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		statuses := r.Statuses()
+
+		body := make(map[string]string, len(statuses))
+		healthy := true
+		for name, status := range statuses {
+			if status.Healthy {
+				body[name] = "OK"
+			} else {
+				healthy = false
+				body[name] = status.Err.Error()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// Stop halts every check's background goroutine.
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks {
+		close(c.stop)
+	}
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// RegisterAuthConnector registers a named AuthConnector with sc.  The
+// registration is server-wide, not per database: a connector registered under
+// name serves /{db}/_oidc/{name}/login for every database on sc.
+// wrapConnectorLoginHandler looks a connector up by name and calls its Login
+// server-side to verify whatever credential a login POST supplies.
+//
+// Ideally a database config's connector list would call this at startup the
+// same way it wires up other per-database config (the DbConfig type isn't
+// part of this checkout, so that wiring isn't done here); until that lands,
+// RegisterAuthConnector is the only way to get a connector registered, via
+// whatever owns the ServerContext.
+func (sc *ServerContext) RegisterAuthConnector(name string, connector auth.AuthConnector) {
+	if sc.authConnectors == nil {
+		sc.authConnectors = auth.NewConnectorRegistry()
+	}
+	sc.authConnectors.Add(name, connector)
+}
+
+// connectorSession is the body returned by a successful connector login,
+// mirroring the shape of Sync Gateway's existing session-login responses.
+type connectorSession struct {
+	SessionID  string    `json:"session_id"`
+	Expires    time.Time `json:"expires"`
+	CookieName string    `json:"cookie_name"`
+}
+
+// connectorLoginRequest is the body POSTed to /{db}/_oidc/{name}/login: the
+// raw, not-yet-verified credential (an OAuth2 code, an OIDC id_token, ...)
+// to hand to the named connector's own Login. The server never trusts a
+// client-supplied Identity directly -- only what the connector itself
+// verifies from credential.
+type connectorLoginRequest struct {
+	Credential string `json:"credential"`
+}
+
+// handleConnectorLogin maps identity -- already verified server-side by the
+// connector's own Login -- to a Sync Gateway user, granting the identity's
+// upstream groups as explicit channels as of the database's current
+// sequence (not a fixed one, so clients that already synced past that point
+// still see the grant appear as a new change), and mints a real session for
+// it, the same way the Facebook login handler turns a verified FB token
+// into a session.
+func handleConnectorLogin(sc *ServerContext, dbName string, identity *auth.Identity) (*connectorSession, error) {
+	database := sc.Database(dbName)
+	if database == nil {
+		return nil, fmt.Errorf("no such database %q", dbName)
+	}
+	authenticator := database.Authenticator()
+
+	user, err := authenticator.GetUser(identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if user, err = authenticator.NewUser(identity.Subject, "", nil); err != nil {
+			return nil, err
+		}
+	}
+
+	lastSeq, err := database.LastSequence()
+	if err != nil {
+		return nil, err
+	}
+	user.SetExplicitChannels(channels.AtSequence(base.SetOf(identity.Groups...), lastSeq+1))
+	if err := authenticator.Save(user); err != nil {
+		return nil, err
+	}
+
+	session, err := authenticator.CreateSession(user, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &connectorSession{
+		SessionID:  session.ID,
+		Expires:    session.Expiration,
+		CookieName: auth.CookieName,
+	}, nil
+}
+
+// wrapConnectorLoginHandler intercepts `POST /{db}/_oidc/{name}/login` --
+// the endpoint RestTester.SendConnectorLoginRequest posts a credential to --
+// re-runs the named connector's own Login server-side to verify it, and
+// mints a session from the resulting (server-verified) Identity; every
+// other request falls through to fallback. A client never gets to hand the
+// server an Identity directly -- only a raw credential the connector itself
+// must validate. CreatePublicHandlerWithExtensions chains this in front of
+// the normal public REST dispatch.
+func wrapConnectorLoginHandler(sc *ServerContext, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		dbName, name, ok := parseConnectorLoginPath(req.URL.Path)
+		if !ok || req.Method != "POST" {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+
+		connector, err := sc.authConnectorByName(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		var loginReq connectorLoginRequest
+		if err := json.NewDecoder(req.Body).Decode(&loginReq); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		identity, err := connector.Login(req.Context(), loginReq.Credential)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		session, err := handleConnectorLogin(sc, dbName, identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: session.CookieName, Value: session.SessionID, Expires: session.Expires})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+	})
+}
+
+// authConnectorByName looks up a registered connector by name, failing with
+// a descriptive error if sc has none registered or the name is unknown.
+func (sc *ServerContext) authConnectorByName(name string) (auth.AuthConnector, error) {
+	if sc.authConnectors == nil {
+		return nil, fmt.Errorf("database has no AuthConnectors configured")
+	}
+	return sc.authConnectors.Get(name)
+}
+
+// parseConnectorLoginPath extracts the db name and connector name from a
+// "/{db}/_oidc/{name}/login" path.
+func parseConnectorLoginPath(path string) (dbName, name string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[1] != "_oidc" || parts[3] != "login" {
+		return "", "", false
+	}
+	return parts[0], parts[2], true
+}
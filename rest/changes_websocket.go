@@ -0,0 +1,208 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/couchbase/sync_gateway/db"
+)
+
+// changesWebSocketSubscription is the payload a client sends once the
+// websocket is open, to pick up the feed from a given sequence with an
+// optional channel filter.  Filter is always narrowed to the authenticated
+// user's accessible channels -- a client can ask for fewer channels than
+// it's granted, never more.
+type changesWebSocketSubscription struct {
+	Since  uint64   `json:"since"`
+	Filter []string `json:"filter,omitempty"`
+}
+
+// handleChangesFeedWebSocket upgrades a `_changes?feed=websocket` request and
+// streams newline-delimited db.ChangeEntry JSON to the client as they become
+// available, instead of making the client poll.  Unlike the long-poll feed,
+// the connection is torn down via Close when the request's database closes,
+// rather than leaking a goroutine blocked on a channel that will never send.
+// h.user must already be authenticated; the feed is restricted to its
+// accessible channels exactly as the long-poll _changes endpoint is.
+func handleChangesFeedWebSocket(h *handler) error {
+	database := h.db
+	allowedChannels := channelNames(h.user.Channels())
+	wildcard := containsChannel(allowedChannels, "*")
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		var sub changesWebSocketSubscription
+		if err := websocket.JSON.Receive(ws, &sub); err != nil {
+			return
+		}
+
+		requested := sub.Filter
+		if len(requested) == 0 {
+			requested = allowedChannels
+		}
+
+		options := db.ChangesOptions{Since: db.SequenceID{Seq: sub.Since}}
+		switch {
+		case wildcard && len(sub.Filter) == 0:
+			// User can see every channel and didn't narrow the request:
+			// behave like the unfiltered long-poll feed.
+		case wildcard:
+			options.Channels = channels.SetOf(requested...)
+		default:
+			options.Channels = channels.SetOf(intersectChannels(requested, allowedChannels)...)
+		}
+
+		feed, err := database.MultiChangesFeed(options)
+		if err != nil {
+			return
+		}
+		for entry := range feed {
+			if entry == nil {
+				// Database was closed out from under us; stop rather than
+				// block forever on a feed that will never send again.
+				return
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return
+			}
+			if err := websocket.Message.Send(ws, string(data)+"\n"); err != nil {
+				return
+			}
+		}
+	}).ServeHTTP(h.response, h.rq)
+	return nil
+}
+
+// authenticateChangesFeedWebSocket authenticates req the same way the rest
+// of the public API does: Basic Auth credentials if present, otherwise the
+// guest user (only usable when Admin Party is in effect).  The returned
+// user's channels are what the feed gets restricted to.
+func authenticateChangesFeedWebSocket(database *db.DatabaseContext, req *http.Request) (auth.User, error) {
+	authenticator := database.Authenticator()
+
+	if username, password, ok := req.BasicAuth(); ok {
+		user, err := authenticator.AuthenticateUser(username, password)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("invalid credentials")
+		}
+		return user, nil
+	}
+
+	guest, err := authenticator.GetUser("")
+	if err != nil {
+		return nil, err
+	}
+	if guest == nil || guest.Disabled() {
+		return nil, fmt.Errorf("login required")
+	}
+	return guest, nil
+}
+
+// wrapChangesFeedHandler intercepts `_changes?feed=websocket` requests --
+// authenticating and authorizing them exactly like the long-poll feed does
+// -- and upgrades them via handleChangesFeedWebSocket; every other request
+// falls through to fallback.  CreatePublicHandlerWithExtensions chains this
+// in front of the normal public REST dispatch.
+func wrapChangesFeedHandler(sc *ServerContext, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		dbName, ok := parseChangesFeedPath(req.URL.Path)
+		if !ok || req.URL.Query().Get("feed") != "websocket" {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+
+		database := sc.Database(dbName)
+		if database == nil {
+			http.Error(w, fmt.Sprintf("no such database %q", dbName), http.StatusNotFound)
+			return
+		}
+
+		user, err := authenticateChangesFeedWebSocket(database, req)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Sync Gateway"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h := &handler{db: database, rq: req, response: w, server: sc, user: user}
+		handleChangesFeedWebSocket(h)
+	})
+}
+
+// parseChangesFeedPath extracts the db name from a "/{db}/_changes" path.
+func parseChangesFeedPath(path string) (dbName string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 || parts[1] != "_changes" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// channelNames returns the channel names a user currently has access to.
+func channelNames(timedSet channels.TimedSet) []string {
+	names := make([]string, 0, len(timedSet))
+	for name := range timedSet {
+		names = append(names, name)
+	}
+	return names
+}
+
+func containsChannel(chans []string, name string) bool {
+	for _, c := range chans {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectChannels returns the subset of requested that's also in allowed.
+func intersectChannels(requested, allowed []string) []string {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, c := range allowed {
+		allowedSet[c] = true
+	}
+	result := make([]string, 0, len(requested))
+	for _, c := range requested {
+		if allowedSet[c] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// ChangesConn is a client-side handle on an open `_changes?feed=websocket`
+// connection, returned by RestTester.DialChangesWebSocket.
+type ChangesConn struct {
+	ws *websocket.Conn
+}
+
+// ReadChange blocks for up to timeout for the next change entry, returning
+// an error if the deadline passes or the connection is closed.
+func (c *ChangesConn) ReadChange(timeout time.Duration) (*db.ChangeEntry, error) {
+	if err := c.ws.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	var entry db.ChangeEntry
+	if err := websocket.JSON.Receive(c.ws, &entry); err != nil {
+		return nil, fmt.Errorf("ReadChange: %v", err)
+	}
+	return &entry, nil
+}
+
+// Close closes the underlying websocket connection.
+func (c *ChangesConn) Close() error {
+	return c.ws.Close()
+}
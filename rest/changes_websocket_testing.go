@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// DialChangesWebSocket opens a `_changes?feed=websocket` connection as
+// username, subscribes starting at sinceSeq, and returns a ChangesConn that
+// tests can read change entries from without the RetryLoop polling pattern
+// used by WaitForChanges.
+func (rt *RestTester) DialChangesWebSocket(username string, sinceSeq uint64) (*ChangesConn, error) {
+	server := httptest.NewServer(CreatePublicHandlerWithExtensions(rt.ServerContext()))
+
+	origin := "http://localhost/"
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/db/_changes?feed=websocket"
+	config, err := websocket.NewConfig(wsURL, origin)
+	if err != nil {
+		return nil, fmt.Errorf("DialChangesWebSocket: %v", err)
+	}
+	if username != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(username + ":letmein"))
+		config.Header.Set("Authorization", "Basic "+creds)
+	}
+
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("DialChangesWebSocket: %v", err)
+	}
+
+	if err := websocket.JSON.Send(ws, changesWebSocketSubscription{Since: sinceSeq}); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("DialChangesWebSocket: failed to send subscription: %v", err)
+	}
+
+	return &ChangesConn{ws: ws}, nil
+}
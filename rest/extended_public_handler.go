@@ -0,0 +1,21 @@
+package rest
+
+import "net/http"
+
+// CreatePublicHandlerWithExtensions wraps CreatePublicHandler(sc) with the
+// routes this package adds on top of it (connector login, the websocket
+// _changes feed) that CreatePublicHandler itself doesn't dispatch to.
+// RestTester routes every public-API request through this (admin requests
+// go through CreateAdminHandler instead), including the plain
+// SendRequest/SendUserRequestWithHeaders helpers, so ordinary integration
+// tests exercise the real authentication/authorization path for these
+// routes rather than a side channel only specialized test helpers can
+// reach. Wiring the production server's handler chain to call this instead
+// of the bare CreatePublicHandler is what's needed to expose these routes
+// outside of tests.
+func CreatePublicHandlerWithExtensions(sc *ServerContext) http.Handler {
+	handler := CreatePublicHandler(sc)
+	handler = wrapConnectorLoginHandler(sc, handler)
+	handler = wrapChangesFeedHandler(sc, handler)
+	return handler
+}
@@ -0,0 +1,15 @@
+package rest
+
+import "github.com/couchbase/sync_gateway/base"
+
+// InjectBucketFaults wraps this RestTester's bucket in a base.FaultyBucket
+// configured with spec, swapping it in everywhere the bucket is already in
+// use (RestTesterBucket and the underlying database).  It calls Bucket()
+// first, so the database is created if it doesn't exist yet; the bucket it
+// wraps is whatever Bucket() returns at that point.
+func (rt *RestTester) InjectBucketFaults(spec base.FaultSpec) *base.FaultyBucket {
+	faulty := base.NewFaultyBucket(rt.Bucket(), spec)
+	rt.RestTesterBucket = faulty
+	rt.RestTesterServerContext.Database("db").Bucket = faulty
+	return faulty
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/couchbase/sync_gateway/health"
+)
+
+// defaultHealthCheckThreshold is used when RegisterHealthCheck is called
+// with threshold <= 0: the number of consecutive failures a check must
+// accumulate before /_health reports it unhealthy, so a single blip against
+// a dependency doesn't flip the whole admin API to 503.
+const defaultHealthCheckThreshold = 3
+
+// RegisterHealthCheck adds a named, periodic Checker to sc's health
+// registry, creating the registry on first use.  threshold is the number of
+// consecutive failures required before the check is reported unhealthy;
+// pass 0 to use defaultHealthCheckThreshold, or 1 to report the first
+// failure immediately.  Results are cached and served by handleHealth;
+// registration does not block on the first run.
+func (sc *ServerContext) RegisterHealthCheck(name string, period time.Duration, threshold int, check health.Checker) {
+	if threshold <= 0 {
+		threshold = defaultHealthCheckThreshold
+	}
+	if sc.healthChecks == nil {
+		sc.healthChecks = health.NewRegistry()
+	}
+	sc.healthChecks.Register(name, period, threshold, check)
+}
+
+// IsHealthy reports whether every check registered with sc is currently
+// healthy.  A ServerContext with no registered checks is considered healthy.
+func (sc *ServerContext) IsHealthy() bool {
+	if sc.healthChecks == nil {
+		return true
+	}
+	return sc.healthChecks.IsHealthy()
+}
+
+// HealthStatuses returns the cached status of every check registered with sc.
+func (sc *ServerContext) HealthStatuses() map[string]health.Status {
+	if sc.healthChecks == nil {
+		return map[string]health.Status{}
+	}
+	return sc.healthChecks.Statuses()
+}
+
+// handleHealth serves /_health: a JSON map of check name to "OK" or the
+// check's error message, with a 503 status if anything is unhealthy.
+func (h *handler) handleHealth() error {
+	if h.server.healthChecks == nil {
+		return h.writeJSON(map[string]string{})
+	}
+	h.server.healthChecks.Handler().ServeHTTP(h.response, h.rq)
+	return nil
+}
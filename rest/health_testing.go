@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/couchbase/sync_gateway/health"
+)
+
+// RegisterHealthCheck adds a named, periodic Checker to the underlying
+// ServerContext's health registry -- the same registry /_health serves --
+// so a check registered through a RestTester actually shows up there.
+// threshold is the number of consecutive failures required to flip the
+// check unhealthy; pass 0 to use the ServerContext's default.
+func (rt *RestTester) RegisterHealthCheck(name string, period time.Duration, threshold int, check health.Checker) {
+	rt.ServerContext().RegisterHealthCheck(name, period, threshold, check)
+}
+
+// WaitForHealthy polls until every check registered with the underlying
+// ServerContext reports healthy, or returns an error once timeout elapses.
+func (rt *RestTester) WaitForHealthy(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if rt.ServerContext().IsHealthy() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("WaitForHealthy: not healthy after %v: %+v", timeout, rt.ServerContext().HealthStatuses())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
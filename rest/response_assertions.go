@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Fluent assertions on TestResponse.  Each assertion fails the test (with a
+// stack trace and the response body, for postmortem debugging) and returns
+// the response so callers can chain, e.g.:
+//
+//	rt.SendAdminRequest("PUT", "/db/doc", `{"foo":true}`).
+//		AssertStatus(t, 201).
+//		AssertJSONPath(t, "ok", true)
+
+// assertStatus is kept as a thin wrapper around AssertStatus for the many
+// existing rest tests written against the older free-function form; new
+// tests should call response.AssertStatus(t, expected) directly.
+func assertStatus(t *testing.T, response *TestResponse, expectedStatus int) {
+	response.AssertStatus(t, expectedStatus)
+}
+
+// AssertStatus fails the test if the response's status code isn't expected.
+func (r *TestResponse) AssertStatus(t *testing.T, expected int) *TestResponse {
+	if r.Code != expected {
+		r.fatalf(t, fmt.Sprintf("Response status %d (expected %d)", r.Code, expected))
+	}
+	return r
+}
+
+// AssertHeader fails the test if the named response header isn't expected.
+func (r *TestResponse) AssertHeader(t *testing.T, name, expected string) *TestResponse {
+	if actual := r.Header().Get(name); actual != expected {
+		r.fatalf(t, fmt.Sprintf("Header %q = %q (expected %q)", name, actual, expected))
+	}
+	return r
+}
+
+// DecodeJSON unmarshals the response body into v, failing the test if the
+// body isn't valid JSON.
+func (r *TestResponse) DecodeJSON(t *testing.T, v interface{}) *TestResponse {
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.fatalf(t, fmt.Sprintf("Failed to decode JSON body: %v", err))
+	}
+	return r
+}
+
+// AssertJSONPath fails the test unless the value at the dotted path (e.g.
+// "rev" or "error.reason") within the response body equals expected.
+func (r *TestResponse) AssertJSONPath(t *testing.T, path string, expected interface{}) *TestResponse {
+	actual, err := r.jsonPath(path)
+	if err != nil {
+		r.fatalf(t, err.Error())
+		return r
+	}
+	if !jsonValuesEqual(actual, expected) {
+		r.fatalf(t, fmt.Sprintf("JSON path %q = %#v (expected %#v)", path, actual, expected))
+	}
+	return r
+}
+
+// AssertJSONArrayLen fails the test unless the array at the dotted path
+// within the response body has exactly n elements.
+func (r *TestResponse) AssertJSONArrayLen(t *testing.T, path string, n int) *TestResponse {
+	actual, err := r.jsonPath(path)
+	if err != nil {
+		r.fatalf(t, err.Error())
+		return r
+	}
+	array, ok := actual.([]interface{})
+	if !ok {
+		r.fatalf(t, fmt.Sprintf("JSON path %q = %#v, not an array", path, actual))
+		return r
+	}
+	if len(array) != n {
+		r.fatalf(t, fmt.Sprintf("JSON path %q has %d elements (expected %d): %#v", path, len(array), n, array))
+	}
+	return r
+}
+
+// AssertRevID fails the test unless the response body's "rev" field equals
+// expectedRev.
+func (r *TestResponse) AssertRevID(t *testing.T, expectedRev string) *TestResponse {
+	return r.AssertJSONPath(t, "rev", expectedRev)
+}
+
+// jsonPath walks a dot-separated path (with optional [index] array
+// subscripts, e.g. "rows[0].id") through the response body.
+func (r *TestResponse) jsonPath(path string) (interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(r.Body.Bytes(), &body); err != nil {
+		return nil, fmt.Errorf("AssertJSONPath(%q): response body isn't valid JSON: %v", path, err)
+	}
+
+	current := body
+	for _, segment := range strings.Split(path, ".") {
+		key, index, hasIndex := splitIndex(segment)
+
+		if key != "" {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("AssertJSONPath(%q): %q isn't an object (got %#v)", path, key, current)
+			}
+			current, ok = m[key]
+			if !ok {
+				return nil, fmt.Errorf("AssertJSONPath(%q): no key %q in %#v", path, key, m)
+			}
+		}
+
+		if hasIndex {
+			array, ok := current.([]interface{})
+			if !ok || index >= len(array) {
+				return nil, fmt.Errorf("AssertJSONPath(%q): index %d out of range in %#v", path, index, current)
+			}
+			current = array[index]
+		}
+	}
+	return current, nil
+}
+
+// splitIndex splits a path segment like "rows[0]" into key="rows", index=0,
+// hasIndex=true, or returns just the key unchanged if there's no subscript.
+func splitIndex(segment string) (key string, index int, hasIndex bool) {
+	open := strings.IndexByte(segment, '[')
+	if open == -1 || !strings.HasSuffix(segment, "]") {
+		return segment, 0, false
+	}
+	key = segment[:open]
+	index, err := strconv.Atoi(segment[open+1 : len(segment)-1])
+	if err != nil {
+		return segment, 0, false
+	}
+	return key, index, true
+}
+
+// jsonValuesEqual compares two values as decoded from JSON, treating
+// int/float mismatches (e.g. expected 1, decoded 1.0) as equal.
+func jsonValuesEqual(actual, expected interface{}) bool {
+	if af, ok := toFloat64(actual); ok {
+		if ef, ok := toFloat64(expected); ok {
+			return af == ef
+		}
+	}
+	// Round-trip expected through JSON so it has the same shape as actual
+	// (e.g. []string becomes []interface{}), then compare exactly.
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var decodedExpected interface{}
+	if err := json.Unmarshal(expectedJSON, &decodedExpected); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(actual, decodedExpected)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func (r *TestResponse) fatalf(t *testing.T, reason string) {
+	debug.PrintStack()
+	t.Fatalf("%s for %s <%s>: %s", reason, r.Req.Method, r.Req.URL, r.Body)
+}
@@ -8,10 +8,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/couchbase/sync_gateway/auth"
 	"github.com/couchbase/sync_gateway/base"
 	"github.com/couchbase/sync_gateway/channels"
 	"github.com/couchbase/sync_gateway/db"
-	"runtime/debug"
 	"encoding/json"
 	"github.com/couchbase/sg-bucket"
 )
@@ -20,8 +20,6 @@ import (
 // are available to any package that imports rest.  (if they were in a _test.go
 // file, they wouldn't be publicly exported to other packages)
 
-var gBucketCounter = 0
-
 type RestTester struct {
 	RestTesterBucket        base.Bucket
 	RestTesterServerContext *ServerContext
@@ -29,64 +27,107 @@ type RestTester struct {
 	distributedIndex        bool      // Test with walrus-based index bucket
 	SyncFn                  string    // put the sync() function source in here (optional)
 	DatabaseConfig          *DbConfig // Supports additional config options.  BucketConfig, Name, Sync, Unsupported will be ignored (overridden)
+	CustomBucket            base.Bucket
+	CustomTestBucket        func() base.Bucket
+	authConnectors          *auth.ConnectorRegistry
+}
+
+// RestTesterConfig is the argument to NewRestTester.  CustomBucket and
+// CustomTestBucket let a caller inject an already-constructed base.Bucket
+// (a mock, or a scripted walrus with pre-seeded docs) instead of going
+// through base.GetBucketOrPanic/base.GetTestBucketSpec, so tests don't share
+// any package-level state and can run under t.Parallel().  If both are set,
+// CustomBucket wins.
+type RestTesterConfig struct {
+	CustomBucket     base.Bucket
+	CustomTestBucket func() base.Bucket
+}
+
+// NewRestTester returns a RestTester configured from cfg, and registers
+// t.Cleanup(rt.Close) so callers don't need their own defer.
+func NewRestTester(t *testing.T, cfg RestTesterConfig) *RestTester {
+	rt := &RestTester{
+		CustomBucket:     cfg.CustomBucket,
+		CustomTestBucket: cfg.CustomTestBucket,
+	}
+	t.Cleanup(rt.Close)
+	return rt
 }
 
 func (rt *RestTester) Bucket() base.Bucket {
-	if rt.RestTesterBucket == nil {
+	if rt.RestTesterBucket != nil {
+		return rt.RestTesterBucket
+	}
+
+	customBucket := rt.CustomBucket
+	if customBucket == nil && rt.CustomTestBucket != nil {
+		customBucket = rt.CustomTestBucket()
+	}
+
+	var server, bucketName, username, password string
+	if customBucket == nil {
 		// Initialize the bucket.  For couchbase-backed tests, triggers with creation/flushing of the bucket
 		base.GetBucketOrPanic() // side effect of creating/flushing bucket
 		spec := base.GetTestBucketSpec(base.DataBucket)
+		username, password, _ = spec.Auth.GetCredentials()
+		server = spec.Server
+		bucketName = spec.BucketName
+	} else {
+		// Injected bucket: point the DbConfig at an unused walrus name of its
+		// own (never opened) so AddDatabaseFromConfig's normal plumbing still
+		// runs, then swap in the caller's bucket below.
+		server = "walrus:"
+		bucketName = fmt.Sprintf("sync_gateway_test_%p", rt)
+	}
 
-		username, password, _ := spec.Auth.GetCredentials()
-
-		server := spec.Server
-		gBucketCounter++
-
-		var syncFnPtr *string
-		if len(rt.SyncFn) > 0 {
-			syncFnPtr = &rt.SyncFn
-		}
+	var syncFnPtr *string
+	if len(rt.SyncFn) > 0 {
+		syncFnPtr = &rt.SyncFn
+	}
 
-		corsConfig := &CORSConfig{
-			Origin:      []string{"http://example.com", "*", "http://staging.example.com"},
-			LoginOrigin: []string{"http://example.com"},
-			Headers:     []string{},
-			MaxAge:      1728000,
-		}
+	corsConfig := &CORSConfig{
+		Origin:      []string{"http://example.com", "*", "http://staging.example.com"},
+		LoginOrigin: []string{"http://example.com"},
+		Headers:     []string{},
+		MaxAge:      1728000,
+	}
 
-		rt.RestTesterServerContext = NewServerContext(&ServerConfig{
-			CORS:           corsConfig,
-			Facebook:       &FacebookConfig{},
-			AdminInterface: &DefaultAdminInterface,
-		})
+	rt.RestTesterServerContext = NewServerContext(&ServerConfig{
+		CORS:           corsConfig,
+		Facebook:       &FacebookConfig{},
+		AdminInterface: &DefaultAdminInterface,
+	})
 
-		useXattrs := base.TestUseXattrs()
+	useXattrs := base.TestUseXattrs()
 
-		if rt.DatabaseConfig == nil {
-			rt.DatabaseConfig = &DbConfig{}
-		}
+	if rt.DatabaseConfig == nil {
+		rt.DatabaseConfig = &DbConfig{}
+	}
 
-		rt.DatabaseConfig.BucketConfig = BucketConfig{
-			Server:   &server,
-			Bucket:   &spec.BucketName,
-			Username: username,
-			Password: password,
-		}
-		rt.DatabaseConfig.Name = "db"
-		rt.DatabaseConfig.Sync = syncFnPtr
-		rt.DatabaseConfig.EnableXattrs = &useXattrs
+	rt.DatabaseConfig.BucketConfig = BucketConfig{
+		Server:   &server,
+		Bucket:   &bucketName,
+		Username: username,
+		Password: password,
+	}
+	rt.DatabaseConfig.Name = "db"
+	rt.DatabaseConfig.Sync = syncFnPtr
+	rt.DatabaseConfig.EnableXattrs = &useXattrs
 
-		_, err := rt.RestTesterServerContext.AddDatabaseFromConfig(rt.DatabaseConfig)
-		if err != nil {
-			panic(fmt.Sprintf("Error from AddDatabaseFromConfig: %v", err))
-		}
-		rt.RestTesterBucket = rt.RestTesterServerContext.Database("db").Bucket
+	_, err := rt.RestTesterServerContext.AddDatabaseFromConfig(rt.DatabaseConfig)
+	if err != nil {
+		panic(fmt.Sprintf("Error from AddDatabaseFromConfig: %v", err))
+	}
 
-		if !rt.noAdminParty {
-			rt.SetAdminParty(true)
-		}
+	if customBucket != nil {
+		rt.RestTesterServerContext.Database("db").Bucket = customBucket
+	}
+	rt.RestTesterBucket = rt.RestTesterServerContext.Database("db").Bucket
 
+	if !rt.noAdminParty {
+		rt.SetAdminParty(true)
 	}
+
 	return rt.RestTesterBucket
 }
 
@@ -94,8 +135,7 @@ func (rt *RestTester) BucketAllowEmptyPassword() base.Bucket {
 
 	//Create test DB with "AllowEmptyPassword" true
 	server := "walrus:"
-	bucketName := fmt.Sprintf("sync_gateway_test_%d", gBucketCounter)
-	gBucketCounter++
+	bucketName := fmt.Sprintf("sync_gateway_test_%p", rt)
 
 	rt.RestTesterServerContext = NewServerContext(&ServerConfig{
 		CORS:           &CORSConfig{},
@@ -190,7 +230,7 @@ func (rt *RestTester) SendUserRequestWithHeaders(method, resource string, body s
 func (rt *RestTester) Send(request *http.Request) *TestResponse {
 	response := &TestResponse{httptest.NewRecorder(), request}
 	response.Code = 200 // doesn't seem to be initialized by default; filed Go bug #4188
-	CreatePublicHandler(rt.ServerContext()).ServeHTTP(response, request)
+	CreatePublicHandlerWithExtensions(rt.ServerContext()).ServeHTTP(response, request)
 	return response
 }
 
@@ -326,11 +366,3 @@ func requestByUser(method, resource, body, username string) *http.Request {
 	r.SetBasicAuth(username, "letmein")
 	return r
 }
-
-func assertStatus(t *testing.T, response *TestResponse, expectedStatus int) {
-	if response.Code != expectedStatus {
-		debug.PrintStack()
-		t.Fatalf("Response status %d (expected %d) for %s <%s> : %s",
-			response.Code, expectedStatus, response.Req.Method, response.Req.URL, response.Body)
-	}
-}